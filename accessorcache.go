@@ -0,0 +1,297 @@
+package dagstore
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/filecoin-project/dagstore/mount"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/ipld/go-car/v2/index"
+)
+
+// AccessorCacheStats is a point-in-time snapshot of accessorCache
+// occupancy and effectiveness, suitable for exposing via metrics.
+type AccessorCacheStats struct {
+	Hits      uint64
+	Misses    uint64
+	Evictions uint64
+	Size      int
+}
+
+// accessorCacheEntry holds the fetched mount.Reader and index.Index for a
+// shard, shared across every acquireAsync caller that asks for that shard
+// while the entry is live. The first caller to observe a miss populates
+// loaded/err and closes ready; subsequent callers block on ready instead
+// of calling mnt.Fetch again.
+//
+// elem tracks this entry's position in the cache's LRU list, and doubles
+// as the "is this still the current, authoritative entry for key" flag:
+// it's set to nil exactly when the entry is detached from the cache's
+// bookkeeping (superseded by a fresher entry for the same key, evicted
+// under size pressure, or explicitly Invalidated), at which point the
+// entry is closed as soon as its last outstanding reference drops, no
+// matter who is holding that reference or when they release it.
+type accessorCacheEntry struct {
+	key shard.Key
+
+	ready chan struct{} // closed once loaded/err are safe to read
+	err   error
+
+	reader mount.Reader
+	idx    index.Index
+
+	mu        sync.Mutex // guards refs; entry-local so release doesn't need the cache lock to decrement
+	refs      int
+	expiresAt time.Time
+	elem      *list.Element // position in the LRU list; guarded by accessorCache.mu
+}
+
+// accessorCache is a bounded, ref-counted cache of live shard readers and
+// indices, keyed by shard.Key. It exists so that N concurrent AcquireShard
+// calls for the same shard perform mnt.Fetch and indices.GetFullIndex
+// exactly once between them: the first caller populates the entry, the
+// rest share it, and each caller still gets an independent *ShardAccessor
+// view constructed over the shared reader.
+//
+// Entries are evicted on TTL expiry, on explicit Invalidate (wired to
+// OpShardDestroy via DAGStore.handleShardDestroy), and on size pressure
+// (least-recently-used first), but never while refs > 0 - an entry in use
+// is only closed once the last holder releases it, at which point it's
+// torn down if it was already detached from the cache.
+type accessorCache struct {
+	maxSize int
+	ttl     time.Duration
+
+	mu      sync.Mutex
+	entries map[shard.Key]*accessorCacheEntry
+	lru     *list.List // most-recently-used at the front
+
+	hits, misses, evictions uint64
+}
+
+// newAccessorCache constructs an accessorCache. maxSize <= 0 means
+// unbounded (only TTL and explicit invalidation evict). ttl <= 0 means
+// entries never expire on their own.
+func newAccessorCache(maxSize int, ttl time.Duration) *accessorCache {
+	return &accessorCache{
+		maxSize: maxSize,
+		ttl:     ttl,
+		entries: make(map[shard.Key]*accessorCacheEntry),
+		lru:     list.New(),
+	}
+}
+
+// acquire returns the shared reader and index for k, invoking load on a
+// cache miss, along with a release func the caller must invoke exactly
+// once when done with the returned reader/index (typically after
+// constructing and eventually closing its ShardAccessor). The release
+// func is bound to the specific entry this call observed, so it's safe to
+// call even after a later caller has caused that entry to be superseded
+// or invalidated.
+func (c *accessorCache) acquire(ctx context.Context, k shard.Key, load func(ctx context.Context) (mount.Reader, index.Index, error)) (mount.Reader, index.Index, func(), error) {
+	c.mu.Lock()
+	if e, ok := c.entries[k]; ok {
+		if !c.expired(e) {
+			e.mu.Lock()
+			e.refs++
+			e.mu.Unlock()
+			c.lru.MoveToFront(e.elem)
+			c.mu.Unlock()
+			atomic.AddUint64(&c.hits, 1)
+
+			select {
+			case <-e.ready:
+			case <-ctx.Done():
+				// We already took a ref above but will never observe
+				// e.err/e.reader ourselves; release it so the entry can
+				// still be torn down once its loader (or any other
+				// waiter) is done with it, instead of leaking a
+				// reference that nothing will ever drop.
+				c.releaseEntry(e)
+				return nil, nil, nil, ctx.Err()
+			}
+			release := c.releaseFunc(e)
+			if e.err != nil {
+				release()
+				return nil, nil, nil, e.err
+			}
+			return e.reader, e.idx, release, nil
+		}
+		// e is expired: detach it from the map/LRU right now so the
+		// replacement below doesn't collide with it. Any caller still
+		// holding a reference on e keeps its own release func bound
+		// directly to e, so it's closed correctly once they're done,
+		// independent of what happens to the entry we're about to
+		// install in its place. If nobody holds a reference, close it
+		// here, since no future release will.
+		c.detachLocked(e)
+		e.mu.Lock()
+		idle := e.refs <= 0
+		e.mu.Unlock()
+		if idle {
+			go c.closeEntry(e)
+		}
+	}
+
+	atomic.AddUint64(&c.misses, 1)
+	e := &accessorCacheEntry{key: k, ready: make(chan struct{}), refs: 1}
+	e.elem = c.lru.PushFront(e)
+	c.entries[k] = e
+	c.evictLocked()
+	c.mu.Unlock()
+
+	e.reader, e.idx, e.err = load(ctx)
+	if c.ttl > 0 {
+		e.expiresAt = time.Now().Add(c.ttl)
+	}
+	close(e.ready)
+
+	release := c.releaseFunc(e)
+	if e.err != nil {
+		release()
+		return nil, nil, nil, e.err
+	}
+	return e.reader, e.idx, release, nil
+}
+
+// releaseFunc returns a release callback bound to e, safe to call even
+// after e has been detached from the cache by a later acquire or an
+// explicit Invalidate. It's idempotent in the face of being called more
+// than once, though callers are expected to call it exactly once.
+func (c *accessorCache) releaseFunc(e *accessorCacheEntry) func() {
+	var once sync.Once
+	return func() {
+		once.Do(func() { c.releaseEntry(e) })
+	}
+}
+
+// releaseEntry drops a reference taken by acquire. When the last
+// reference on an entry that's been detached (superseded, evicted, or
+// invalidated) or has expired goes away, the underlying reader is closed.
+func (c *accessorCache) releaseEntry(e *accessorCacheEntry) {
+	e.mu.Lock()
+	e.refs--
+	refs := e.refs
+	e.mu.Unlock()
+	if refs > 0 {
+		return
+	}
+
+	c.mu.Lock()
+	stale := e.elem == nil
+	if !stale && c.expired(e) {
+		c.detachLocked(e)
+		stale = true
+	}
+	c.mu.Unlock()
+
+	if stale {
+		c.closeEntry(e)
+	}
+}
+
+// Invalidate marks k's entry for removal; it's called from
+// DAGStore.handleShardDestroy (itself invoked by the OpShardDestroy case
+// in the event loop) so a destroyed shard's reader isn't kept warm in the
+// cache. If the entry is still referenced, it's torn down as soon as the
+// last reference is released via the holder's own release func.
+func (c *accessorCache) Invalidate(k shard.Key) {
+	c.mu.Lock()
+	e, ok := c.entries[k]
+	if !ok {
+		c.mu.Unlock()
+		return
+	}
+	c.detachLocked(e)
+	e.mu.Lock()
+	refs := e.refs
+	e.mu.Unlock()
+	c.mu.Unlock()
+
+	if refs <= 0 {
+		c.closeEntry(e)
+	}
+}
+
+func (c *accessorCache) expired(e *accessorCacheEntry) bool {
+	return c.ttl > 0 && !e.expiresAt.IsZero() && time.Now().After(e.expiresAt)
+}
+
+// detachLocked removes e from the map and LRU list, marking it as no
+// longer the authoritative entry for its key. Must be called with c.mu
+// held; safe to call on an already-detached entry.
+func (c *accessorCache) detachLocked(e *accessorCacheEntry) {
+	if e.elem == nil {
+		return
+	}
+	if cur, ok := c.entries[e.key]; ok && cur == e {
+		delete(c.entries, e.key)
+	}
+	c.lru.Remove(e.elem)
+	e.elem = nil
+}
+
+// evictLocked detaches least-recently-used entries with no outstanding
+// references until the cache is back under maxSize, closing each one
+// immediately since a zero-ref entry has no future releaser to do it.
+// Must be called with c.mu held.
+func (c *accessorCache) evictLocked() {
+	if c.maxSize <= 0 {
+		return
+	}
+	for elem := c.lru.Back(); len(c.entries) > c.maxSize && elem != nil; {
+		e := elem.Value.(*accessorCacheEntry)
+		prev := elem.Prev()
+		e.mu.Lock()
+		refs := e.refs
+		e.mu.Unlock()
+		if refs <= 0 {
+			c.detachLocked(e)
+			atomic.AddUint64(&c.evictions, 1)
+			go c.closeEntry(e)
+		}
+		elem = prev
+	}
+}
+
+func (c *accessorCache) closeEntry(e *accessorCacheEntry) {
+	<-e.ready
+	if e.reader != nil {
+		if err := e.reader.Close(); err != nil {
+			log.Errorf("failed to close cached mount reader for shard %s: %s", e.key, err)
+		}
+	}
+}
+
+// releasingReader wraps the shared mount.Reader held by an accessorCache
+// entry so that each ShardAccessor built on top of it can be closed
+// independently: Close invokes this caller's release func instead of
+// closing the underlying reader, which the cache itself closes once the
+// last reference goes away.
+type releasingReader struct {
+	mount.Reader
+	release func()
+	once    sync.Once
+}
+
+func (r *releasingReader) Close() error {
+	r.once.Do(r.release)
+	return nil
+}
+
+// Stats returns a snapshot of cache hit/miss/eviction counters and current
+// size.
+func (c *accessorCache) Stats() AccessorCacheStats {
+	c.mu.Lock()
+	size := len(c.entries)
+	c.mu.Unlock()
+	return AccessorCacheStats{
+		Hits:      atomic.LoadUint64(&c.hits),
+		Misses:    atomic.LoadUint64(&c.misses),
+		Evictions: atomic.LoadUint64(&c.evictions),
+		Size:      size,
+	}
+}
@@ -0,0 +1,157 @@
+package dagstore
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/dagstore/mount"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/ipld/go-car/v2/index"
+	"github.com/stretchr/testify/require"
+)
+
+// trackedReader is a mount.Reader whose Close is observable, for asserting
+// exactly when (and whether) the cache actually closes the underlying
+// reader.
+type trackedReader struct {
+	mount.Reader
+	closed int32
+}
+
+func (r *trackedReader) Close() error {
+	atomic.StoreInt32(&r.closed, 1)
+	return nil
+}
+
+func (r *trackedReader) isClosed() bool {
+	return atomic.LoadInt32(&r.closed) == 1
+}
+
+func TestAccessorCacheExpiredEntryDoesNotLeakOrCorruptReplacement(t *testing.T) {
+	c := newAccessorCache(0, 5*time.Millisecond)
+	k := shard.KeyFromString("s1")
+
+	r1 := &trackedReader{}
+	_, _, release1, err := c.acquire(context.Background(), k, func(ctx context.Context) (mount.Reader, index.Index, error) {
+		return r1, nil, nil
+	})
+	require.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond) // let the entry expire while release1 is still outstanding
+
+	r2 := &trackedReader{}
+	reader2, _, release2, err := c.acquire(context.Background(), k, func(ctx context.Context) (mount.Reader, index.Index, error) {
+		return r2, nil, nil
+	})
+	require.NoError(t, err)
+	require.Same(t, r2, reader2)
+
+	// The stale entry must not be torn down while its own holder still
+	// references it, and releasing it must not reach into whatever entry
+	// is now current for k.
+	require.False(t, r1.isClosed(), "expired entry closed while still referenced")
+	require.False(t, r2.isClosed(), "replacement entry closed by an unrelated release")
+
+	release1()
+	require.True(t, r1.isClosed(), "expired entry should close once its last reference is released")
+	require.False(t, r2.isClosed(), "releasing the stale entry must not affect its replacement")
+
+	release2()
+	require.True(t, r2.isClosed())
+
+	stats := c.Stats()
+	require.EqualValues(t, 2, stats.Misses)
+	require.EqualValues(t, 0, stats.Hits)
+}
+
+func TestAccessorCacheInvalidateDuringLoadDoesNotLeakReader(t *testing.T) {
+	c := newAccessorCache(0, 0)
+	k := shard.KeyFromString("s1")
+
+	loadStarted := make(chan struct{})
+	unblockLoad := make(chan struct{})
+	r := &trackedReader{}
+
+	type result struct {
+		release func()
+		err     error
+	}
+	resultCh := make(chan result, 1)
+	go func() {
+		_, _, release, err := c.acquire(context.Background(), k, func(ctx context.Context) (mount.Reader, index.Index, error) {
+			close(loadStarted)
+			<-unblockLoad
+			return r, nil, nil
+		})
+		resultCh <- result{release, err}
+	}()
+
+	<-loadStarted
+	c.Invalidate(k) // races with the in-flight load; entry has refs=1 at this point
+
+	close(unblockLoad)
+	res := <-resultCh
+	require.NoError(t, res.err)
+	require.False(t, r.isClosed(), "reader must stay open while the caller that loaded it hasn't released yet")
+
+	res.release()
+	require.True(t, r.isClosed(), "invalidated entry must close its reader once the last reference is released")
+
+	// A second, unrelated acquire for the same key must miss and load
+	// again rather than finding the invalidated (and now-closed) entry.
+	r2 := &trackedReader{}
+	_, _, release2, err := c.acquire(context.Background(), k, func(ctx context.Context) (mount.Reader, index.Index, error) {
+		return r2, nil, nil
+	})
+	require.NoError(t, err)
+	defer release2()
+	require.False(t, r2.isClosed())
+}
+
+// TestAccessorCacheAcquireJoinerRespectsContextCancellation is the
+// regression test for a caller that joins an in-flight load (cache hit,
+// entry not yet ready): it must return promptly when its own ctx is
+// cancelled, instead of blocking on the loader's ready channel regardless
+// of its own deadline.
+func TestAccessorCacheAcquireJoinerRespectsContextCancellation(t *testing.T) {
+	c := newAccessorCache(0, 0)
+	k := shard.KeyFromString("s1")
+
+	loadStarted := make(chan struct{})
+	unblockLoad := make(chan struct{})
+	r := &trackedReader{}
+
+	go func() {
+		_, _, release, err := c.acquire(context.Background(), k, func(ctx context.Context) (mount.Reader, index.Index, error) {
+			close(loadStarted)
+			<-unblockLoad
+			return r, nil, nil
+		})
+		require.NoError(t, err)
+		release()
+	}()
+	<-loadStarted
+
+	joinCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		_, _, _, err := c.acquire(joinCtx, k, func(ctx context.Context) (mount.Reader, index.Index, error) {
+			t.Error("joiner must not itself become the loader")
+			return nil, nil, nil
+		})
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		require.ErrorIs(t, err, context.Canceled)
+	case <-time.After(time.Second):
+		t.Fatal("acquire did not respect the joining caller's cancelled context")
+	}
+
+	close(unblockLoad)
+}
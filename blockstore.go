@@ -0,0 +1,246 @@
+package dagstore
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	blocks "github.com/ipfs/go-block-format"
+	"github.com/ipfs/go-cid"
+	bstore "github.com/ipfs/go-ipfs-blockstore"
+
+	"github.com/filecoin-project/dagstore/mount"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/ipld/go-car/v2/index"
+)
+
+// ErrReadOnly is returned by every mutating Blockstore method on a
+// shardBlockstore: a shard's contents are immutable once registered, so
+// there's nothing sensible to do with Put/DeleteBlock beyond reporting
+// that it isn't supported.
+var ErrReadOnly = errors.New("dagstore: shard blockstore is read-only")
+
+// ClosableBlockstore is a bstore.Blockstore that also owns resources (a
+// mount.Reader, here) that must be released once the caller is done with
+// it. It matches the interface shape the wider IPFS ecosystem already uses
+// for CARv2-backed blockstores, so it plugs into go-bitswap or any other
+// blockstore consumer without an adapter.
+type ClosableBlockstore interface {
+	bstore.Blockstore
+	io.Closer
+}
+
+// shardBlockstore is a read-only ClosableBlockstore over a shard's
+// FullIndex and mount.Reader: Get/Has/GetSize resolve a CID to a byte
+// offset via the index and read the block directly out of the reader,
+// without needing the caller to build their own CARv2 read-only
+// blockstore first.
+type shardBlockstore struct {
+	key    shard.Key
+	idx    index.Index
+	reader mount.Reader
+}
+
+var _ ClosableBlockstore = (*shardBlockstore)(nil)
+
+// NewShardBlockstore builds a ClosableBlockstore over idx and reader. It
+// does not take ownership of reader's lifecycle beyond Close: closing the
+// returned blockstore closes reader.
+func NewShardBlockstore(key shard.Key, idx index.Index, reader mount.Reader) ClosableBlockstore {
+	return &shardBlockstore{key: key, idx: idx, reader: reader}
+}
+
+func (s *shardBlockstore) offsetFor(c cid.Cid) (uint64, error) {
+	var offset uint64
+	found := false
+	err := s.idx.GetAll(c.Hash(), func(off uint64) bool {
+		offset = off
+		found = true
+		return false // one match is enough
+	})
+	if err != nil {
+		return 0, err
+	}
+	if !found {
+		return 0, bstore.ErrNotFound
+	}
+	return offset, nil
+}
+
+func (s *shardBlockstore) Has(_ context.Context, c cid.Cid) (bool, error) {
+	_, err := s.offsetFor(c)
+	if errors.Is(err, bstore.ErrNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (s *shardBlockstore) Get(_ context.Context, c cid.Cid) (blocks.Block, error) {
+	offset, err := s.offsetFor(c)
+	if err != nil {
+		return nil, err
+	}
+
+	// The index points at the start of the varint-prefixed CARv1 frame
+	// (CID + data); readFrame decodes the length and reads exactly that
+	// many bytes, the same shape car.util.ReadNode uses.
+	data, err := readFrame(s.reader, int64(offset))
+	if err != nil {
+		return nil, err
+	}
+	return blocks.NewBlockWithCid(data, c)
+}
+
+func (s *shardBlockstore) GetSize(ctx context.Context, c cid.Cid) (int, error) {
+	blk, err := s.Get(ctx, c)
+	if err != nil {
+		return 0, err
+	}
+	return len(blk.RawData()), nil
+}
+
+func (s *shardBlockstore) AllKeysChan(ctx context.Context) (<-chan cid.Cid, error) {
+	ch := make(chan cid.Cid)
+	records, err := index.GetIterableIndex(s.idx)
+	if err != nil {
+		return nil, err
+	}
+	go func() {
+		defer close(ch)
+		records.ForEach(func(mh []byte, _ uint64) error {
+			c := cid.NewCidV1(cid.Raw, mh)
+			select {
+			case ch <- c:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return nil
+		})
+	}()
+	return ch, nil
+}
+
+func (s *shardBlockstore) HashOnRead(bool) {}
+
+func (s *shardBlockstore) DeleteBlock(context.Context, cid.Cid) error { return ErrReadOnly }
+func (s *shardBlockstore) Put(context.Context, blocks.Block) error    { return ErrReadOnly }
+func (s *shardBlockstore) PutMany(context.Context, []blocks.Block) error {
+	return ErrReadOnly
+}
+
+func (s *shardBlockstore) Close() error {
+	return s.reader.Close()
+}
+
+// readFrame reads the varint-length-prefixed CARv1 frame starting at
+// offset in reader - a (CID, data) pair - and returns just the data,
+// mirroring the frame shape car.util.LdRead/ReadNode decode. It reads via
+// ReadAt rather than Seek+Read so that concurrent Gets sharing the same
+// mount.Reader (the accessorCache hands out one reader per shard, not one
+// per caller) don't race on a single cursor position.
+func readFrame(reader mount.Reader, offset int64) ([]byte, error) {
+	length, varintLen, err := readUvarintAt(reader, offset)
+	if err != nil {
+		return nil, err
+	}
+
+	frame := make([]byte, length)
+	if _, err := reader.ReadAt(frame, offset+int64(varintLen)); err != nil {
+		return nil, err
+	}
+
+	n, _, err := cid.CidFromBytes(frame)
+	if err != nil {
+		return nil, err
+	}
+	return frame[n:], nil
+}
+
+// maxVarintLen64 is the most bytes a uvarint can take to encode a uint64,
+// matching binary.MaxVarintLen64.
+const maxVarintLen64 = 10
+
+// readUvarintAt decodes the unsigned LEB128 varint located at offset in
+// reader via a single ReadAt, returning the decoded value and the number
+// of bytes the varint itself occupied (so the caller knows where the
+// frame payload following it starts). Reading via ReadAt, rather than
+// Seek-ing to offset and reading byte-by-byte, keeps this safe to call
+// concurrently on the same reader.
+func readUvarintAt(reader mount.Reader, offset int64) (uint64, int, error) {
+	buf := make([]byte, maxVarintLen64)
+	n, err := reader.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return 0, 0, err
+	}
+	buf = buf[:n]
+
+	var x uint64
+	var shift uint
+	for i, b := range buf {
+		if b < 0x80 {
+			return x | uint64(b)<<shift, i + 1, nil
+		}
+		x |= uint64(b&0x7f) << shift
+		shift += 7
+	}
+	return 0, 0, io.ErrUnexpectedEOF
+}
+
+// BlockstoreProvider builds a ClosableBlockstore for a shard once it's
+// been acquired, given its index and underlying reader. DAGStore uses
+// DefaultBlockstoreProvider unless a different one is configured, so
+// callers who want a different block-framing (e.g. raw-only CIDs, or a
+// caching layer) can swap it in without touching acquireAsync.
+type BlockstoreProvider func(key shard.Key, idx index.Index, reader mount.Reader) (ClosableBlockstore, error)
+
+// DefaultBlockstoreProvider returns a shardBlockstore directly over the
+// shard's index and reader.
+func DefaultBlockstoreProvider(key shard.Key, idx index.Index, reader mount.Reader) (ClosableBlockstore, error) {
+	return NewShardBlockstore(key, idx, reader), nil
+}
+
+// BitswapSessionRegistrar lets a DAGStore plug a shard's blockstore into a
+// shared bitswap session as soon as it's acquired, and unplug it on
+// OpShardRelease, without dagstore taking a hard dependency on
+// go-bitswap: the caller supplies whatever glue their bitswap instance
+// needs.
+type BitswapSessionRegistrar interface {
+	RegisterBlockstore(key shard.Key, bs ClosableBlockstore) error
+	UnregisterBlockstore(key shard.Key) error
+}
+
+// blockstoreProviderOrDefault returns d.blockstoreProvider, falling back
+// to DefaultBlockstoreProvider.
+func (d *DAGStore) blockstoreProviderOrDefault() BlockstoreProvider {
+	if d.blockstoreProvider != nil {
+		return d.blockstoreProvider
+	}
+	return DefaultBlockstoreProvider
+}
+
+// registerShardBitswap builds key's blockstore via the configured
+// BlockstoreProvider and, if a BitswapSessionRegistrar is configured,
+// registers it so remote peers can fetch blocks from this shard over
+// bitswap. It's a no-op if no registrar is configured. The blockstore is
+// intentionally left open on success: its lifecycle is tied to
+// OpShardRelease, via unregisterShardBitswap.
+func (d *DAGStore) registerShardBitswap(key shard.Key, idx index.Index, reader mount.Reader) error {
+	if d.bitswapRegistrar == nil {
+		return nil
+	}
+	bs, err := d.blockstoreProviderOrDefault()(key, idx, reader)
+	if err != nil {
+		return err
+	}
+	return d.bitswapRegistrar.RegisterBlockstore(key, bs)
+}
+
+// unregisterShardBitswap undoes registerShardBitswap; it's called from the
+// OpShardRelease handler so a released shard stops serving blocks over
+// bitswap.
+func (d *DAGStore) unregisterShardBitswap(key shard.Key) error {
+	if d.bitswapRegistrar == nil {
+		return nil
+	}
+	return d.bitswapRegistrar.UnregisterBlockstore(key)
+}
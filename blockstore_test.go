@@ -0,0 +1,128 @@
+package dagstore
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/ipfs/go-cid"
+	"github.com/ipld/go-car/util"
+	"github.com/multiformats/go-multihash"
+	"github.com/stretchr/testify/require"
+)
+
+// seeklessReader is a mount.Reader that only implements ReadAt correctly;
+// Seek and sequential Read panic. It exists to prove readFrame resolves a
+// frame purely through ReadAt, so concurrent Gets sharing one reader (the
+// normal case: the accessorCache hands out a single reader per shard) don't
+// race on a shared cursor.
+type seeklessReader struct {
+	data []byte
+}
+
+func (r *seeklessReader) ReadAt(p []byte, off int64) (int, error) {
+	return bytes.NewReader(r.data).ReadAt(p, off)
+}
+
+func (r *seeklessReader) Read([]byte) (int, error) {
+	panic("readFrame must not use Read; it should resolve frames via ReadAt")
+}
+
+func (r *seeklessReader) Seek(int64, int) (int64, error) {
+	panic("readFrame must not use Seek; it should resolve frames via ReadAt")
+}
+
+func (r *seeklessReader) Close() error { return nil }
+
+// buildFrames writes n varint-length-prefixed (CID, data) frames - the same
+// shape a CARv1 payload's body uses - and returns the bytes, the CIDs, and
+// each frame's starting offset.
+func buildFrames(t *testing.T, n int) (payload []byte, cids []cid.Cid, offsets []int64) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		data := bytes.Repeat([]byte{byte(i + 1)}, 8)
+		mh, err := multihash.Sum(data, multihash.SHA2_256, -1)
+		require.NoError(t, err)
+		c := cid.NewCidV1(cid.Raw, mh)
+
+		offsets = append(offsets, int64(buf.Len()))
+		cids = append(cids, c)
+		require.NoError(t, util.LdWrite(&buf, c.Bytes(), data))
+	}
+
+	return buf.Bytes(), cids, offsets
+}
+
+func TestReadFrameResolvesViaReadAtConcurrently(t *testing.T) {
+	payload, cids, offsets := buildFrames(t, 8)
+	reader := &seeklessReader{data: payload}
+
+	var wg sync.WaitGroup
+	for i := range cids {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			data, err := readFrame(reader, offsets[i])
+			require.NoError(t, err)
+			require.Equal(t, bytes.Repeat([]byte{byte(i + 1)}, 8), data)
+		}(i)
+	}
+	wg.Wait()
+}
+
+// fakeBitswapRegistrar tracks RegisterBlockstore/UnregisterBlockstore calls
+// for asserting dagstore's lifecycle wiring without a real bitswap session.
+type fakeBitswapRegistrar struct {
+	mu           sync.Mutex
+	registered   map[shard.Key]ClosableBlockstore
+	unregistered []shard.Key
+}
+
+func newFakeBitswapRegistrar() *fakeBitswapRegistrar {
+	return &fakeBitswapRegistrar{registered: make(map[shard.Key]ClosableBlockstore)}
+}
+
+func (f *fakeBitswapRegistrar) RegisterBlockstore(key shard.Key, bs ClosableBlockstore) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.registered[key] = bs
+	return nil
+}
+
+func (f *fakeBitswapRegistrar) UnregisterBlockstore(key shard.Key) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.unregistered = append(f.unregistered, key)
+	return nil
+}
+
+// TestHandleShardReleaseUnregistersBitswap is the regression test for the
+// OpShardRelease wiring: unregisterShardBitswap existed but nothing in the
+// DAGStore's real dispatch path ever called it, so a released shard kept
+// serving blocks over bitswap indefinitely. This drives the actual
+// completionCh -> event loop -> handleShardRelease path a real
+// OpShardRelease task takes (e.g. the one acquireAsync queues on its
+// error path), rather than calling handleShardRelease directly, so it
+// would have caught the wiring being missing.
+func TestHandleShardReleaseUnregistersBitswap(t *testing.T) {
+	registrar := newFakeBitswapRegistrar()
+	d := NewDAGStore(fakeIndexRepo{}, WithBitswapSessionRegistrar(registrar))
+	k := shard.KeyFromString("s1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, d.Start(ctx))
+
+	require.NoError(t, d.queueTask(&task{op: OpShardRelease, shard: &Shard{key: k}}, d.completionCh))
+
+	require.Eventually(t, func() bool {
+		registrar.mu.Lock()
+		defer registrar.mu.Unlock()
+		return len(registrar.unregistered) == 1 && registrar.unregistered[0] == k
+	}, time.Second, time.Millisecond, "OpShardRelease task should have reached handleShardRelease via the real event loop")
+}
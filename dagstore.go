@@ -0,0 +1,229 @@
+package dagstore
+
+import (
+	"context"
+
+	logging "github.com/ipfs/go-log/v2"
+	"go.opentelemetry.io/otel/metric"
+
+	"github.com/filecoin-project/dagstore/mount"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/ipld/go-car/v2/index"
+)
+
+var log = logging.Logger("dagstore")
+
+// OpType identifies what a queued task asks the DAGStore's event loop to
+// do with a shard.
+type OpType int
+
+const (
+	OpShardFail OpType = iota
+	OpShardMakeAvailable
+	OpShardRelease
+	OpShardDestroy
+)
+
+// task is queued onto a DAGStore's completionCh by acquireAsync/
+// initializeAsync to report a shard's outcome back to the event loop.
+type task struct {
+	op    OpType
+	shard *Shard
+	err   error
+}
+
+// waiter is handed to acquireAsync by whoever triggered the acquire, and
+// used to deliver the eventual ShardResult back to that caller.
+type waiter struct {
+	ch chan *ShardResult
+}
+
+// ShardResult is delivered to an acquire caller once their shard has been
+// fetched and indexed, or has failed to be.
+type ShardResult struct {
+	Key      shard.Key
+	Accessor *ShardAccessor
+	Error    error
+}
+
+// ShardAccessor is handed back to a caller that has successfully acquired
+// a shard. Closing it releases the caller's hold on the shard's reader;
+// once every accessor for a shard has been closed, the accessor cache
+// entry backing it is free to be torn down.
+type ShardAccessor struct {
+	reader mount.Reader
+	idx    index.Index
+	shard  *Shard
+}
+
+// NewShardAccessor builds a ShardAccessor over reader and idx for s.
+// reader is expected to be the releasingReader handed back by
+// accessorCache.acquire, so that Close releases the caller's reference
+// instead of closing the shared underlying reader outright.
+func NewShardAccessor(reader mount.Reader, idx index.Index, s *Shard) (*ShardAccessor, error) {
+	return &ShardAccessor{reader: reader, idx: idx, shard: s}, nil
+}
+
+// Close releases this accessor's hold on its shard's reader.
+func (sa *ShardAccessor) Close() error {
+	return sa.reader.Close()
+}
+
+// Shard tracks the in-memory state DAGStore keeps for one registered shard.
+type Shard struct {
+	key        shard.Key
+	mount      mount.Mount
+	totalBytes int64
+}
+
+// IndexRepo persists and retrieves the full index for a shard. It's the
+// interface d.indices is asked to satisfy, kept narrow to just the two
+// calls acquireAsync/initializeAsync actually make.
+type IndexRepo interface {
+	GetFullIndex(key shard.Key) (index.Index, error)
+	AddFullIndex(key shard.Key, idx index.Index) error
+}
+
+// DAGStore coordinates fetching, indexing, and serving the contents of
+// registered shards. Most of its behaviour is pluggable via Option, so
+// operators can tune throughput, wire in tracing/metrics, or swap in their
+// own checkpoint/bitswap backends without forking the package.
+type DAGStore struct {
+	indices      IndexRepo
+	completionCh chan *task
+
+	accessorCache    *accessorCache
+	shardLocks       shardLocks
+	instrumentation  *instrumentation
+	scheduler        IndexJobScheduler
+	indexingProgress *indexingProgressTracker
+	indexCheckpoints IndexCheckpointStore
+
+	bitswapRegistrar   BitswapSessionRegistrar
+	blockstoreProvider BlockstoreProvider
+}
+
+// Option configures optional DAGStore behaviour at construction time.
+type Option func(*DAGStore)
+
+// WithMeterProvider configures the otel MeterProvider the DAGStore emits
+// fetch/index-generate/acquire/failure metrics to. Without this option, a
+// DAGStore falls back to the global MeterProvider the first time it's
+// needed (see instrumentationOrDefault).
+func WithMeterProvider(mp metric.MeterProvider) Option {
+	return func(d *DAGStore) {
+		inst, err := newInstrumentation(mp)
+		if err != nil {
+			log.Errorf("failed to build instrumentation from supplied MeterProvider, falling back to default: %s", err)
+			return
+		}
+		d.instrumentation = inst
+	}
+}
+
+// WithSchedulerConfig bounds how many fetches/index-generates/index-loads
+// the DAGStore runs concurrently, with interactive acquires prioritized
+// over background initialization. Without this option, scheduling is
+// unbounded (see jobScheduler's noopScheduler fallback).
+func WithSchedulerConfig(cfg SchedulerConfig) Option {
+	return func(d *DAGStore) {
+		d.scheduler = NewBoundedScheduler(cfg)
+	}
+}
+
+// WithIndexCheckpointStore configures where the streaming indexer persists
+// resume checkpoints for large, inline-index-less shards. Without this
+// option, index generation always starts from byte zero.
+func WithIndexCheckpointStore(store IndexCheckpointStore) Option {
+	return func(d *DAGStore) {
+		d.indexCheckpoints = store
+	}
+}
+
+// WithBlockstoreProvider overrides how a shard's ClosableBlockstore is
+// built once acquired. Without this option, DefaultBlockstoreProvider is
+// used.
+func WithBlockstoreProvider(bp BlockstoreProvider) Option {
+	return func(d *DAGStore) {
+		d.blockstoreProvider = bp
+	}
+}
+
+// WithBitswapSessionRegistrar plugs shard blockstores into a shared
+// bitswap session as they're acquired, and unplugs them on release.
+// Without this option, registerShardBitswap/unregisterShardBitswap are
+// no-ops.
+func WithBitswapSessionRegistrar(r BitswapSessionRegistrar) Option {
+	return func(d *DAGStore) {
+		d.bitswapRegistrar = r
+	}
+}
+
+// NewDAGStore constructs a DAGStore backed by indices, applying opts in
+// order. accessorCache and indexingProgress are always initialized to
+// non-nil defaults so the async pipeline never has to nil-check them.
+func NewDAGStore(indices IndexRepo, opts ...Option) *DAGStore {
+	d := &DAGStore{
+		indices:          indices,
+		completionCh:     make(chan *task, 128),
+		accessorCache:    newAccessorCache(0, 0),
+		indexingProgress: newIndexingProgressTracker(),
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// queueTask enqueues t on ch for the event loop to pick up.
+func (d *DAGStore) queueTask(t *task, ch chan *task) error {
+	ch <- t
+	return nil
+}
+
+// sendResult delivers res to w, without blocking if the caller has stopped
+// listening (e.g. because its own context was already cancelled).
+func (d *DAGStore) sendResult(res *ShardResult, w *waiter) {
+	select {
+	case w.ch <- res:
+	default:
+		log.Warnf("failed to deliver shard result for %s: caller is no longer listening", res.Key)
+	}
+}
+
+// Start runs the DAGStore's event loop until ctx is cancelled, dispatching
+// each task queued onto completionCh by acquireAsync/initializeAsync to
+// the corresponding bookkeeping.
+func (d *DAGStore) Start(ctx context.Context) error {
+	go d.control(ctx)
+	return nil
+}
+
+func (d *DAGStore) control(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case t := <-d.completionCh:
+			d.dispatch(t)
+		}
+	}
+}
+
+// dispatch handles one completionCh task. OpShardRelease and OpShardDestroy
+// are where the cross-cutting teardown lives: handleShardRelease/
+// handleShardDestroy, not this switch, own the actual cleanup logic.
+func (d *DAGStore) dispatch(t *task) {
+	switch t.op {
+	case OpShardFail:
+		log.Errorf("shard %s failed: %s", t.shard.key, t.err)
+	case OpShardMakeAvailable:
+		log.Debugf("shard %s is now available", t.shard.key)
+	case OpShardRelease:
+		d.handleShardRelease(t.shard)
+	case OpShardDestroy:
+		d.handleShardDestroy(t.shard)
+	default:
+		log.Errorf("unrecognized task op %d for shard %s", t.op, t.shard.key)
+	}
+}
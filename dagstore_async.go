@@ -3,9 +3,11 @@ package dagstore
 import (
 	"context"
 	"fmt"
+	"time"
 
 	"github.com/filecoin-project/dagstore/mount"
 	"github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/index"
 )
 
 //
@@ -17,37 +19,84 @@ import (
 // joining them to form a ShardAccessor.
 func (d *DAGStore) acquireAsync(ctx context.Context, w *waiter, s *Shard, mnt mount.Mount) {
 	k := s.key
-	reader, err := mnt.Fetch(ctx)
-	if err != nil {
-		// release the shard to decrement the refcount that's incremented before `acquireAsync` is called.
-		_ = d.queueTask(&task{op: OpShardRelease, shard: s}, d.completionCh)
 
-		// fail the shard
-		_ = d.queueTask(&task{op: OpShardFail, shard: s, err: fmt.Errorf("failed to acquire reader of mount: %w", err)}, d.completionCh)
-
-		// send the shard error to the caller.
-		d.sendResult(&ShardResult{Key: k, Error: err}, w)
-		return
-	}
+	ctx, span := startShardSpan(ctx, "DAGStore.acquireAsync", k, mnt)
+	inst := d.instrumentationOrDefault()
+	inst.acquireQueueDepth.Add(ctx, 1)
+	defer inst.acquireQueueDepth.Add(ctx, -1)
+
+	var err error
+	defer func() { endShardSpan(span, err) }()
+
+	// Note: this does *not* take shardLocks. The accessor cache below
+	// already serializes the fetch/index critical section per key on its
+	// own (via the "loading" entry callers block on), and doing so lets N
+	// concurrent acquires of the same shard actually overlap - the first
+	// to arrive fetches and populates the cache, the rest block only as
+	// long as it takes the cache to hand back the shared reader/index,
+	// rather than queuing behind the first caller's entire acquireAsync.
+	// shardLocks is still used by initializeAsync, which has no such
+	// cache to lean on.
+	//
+	// Route the fetch + index lookup through the accessor cache so that N
+	// concurrent acquires of the same shard share a single mnt.Fetch and
+	// indices.GetFullIndex between them; each caller still gets its own
+	// ShardAccessor view over the shared reader via releasingReader.
+	mountKind := mnt.Info().Kind.String()
+	var reader mount.Reader
+	var idx index.Index
+	var release func()
+	reader, idx, release, err = d.accessorCache.acquire(ctx, k, func(ctx context.Context) (mount.Reader, index.Index, error) {
+		var reader mount.Reader
+		start := time.Now()
+		err := withSubSpan(ctx, "mnt.Fetch", func(ctx context.Context) error {
+			return d.jobScheduler().Schedule(ctx, JobClassFetch, PriorityInteractive, mountKind, func() error {
+				var ferr error
+				reader, ferr = mnt.Fetch(ctx)
+				return ferr
+			})
+		})
+		inst.recordFetch(ctx, time.Since(start), mountKind, err)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to acquire reader of mount: %w", err)
+		}
 
-	idx, err := d.indices.GetFullIndex(k)
-	if err != nil {
-		if err := reader.Close(); err != nil {
-			log.Errorf("failed to close mount reader: %s", err)
+		var idx index.Index
+		err = withSubSpan(ctx, "indices.GetFullIndex", func(ctx context.Context) error {
+			var ierr error
+			idx, ierr = d.indices.GetFullIndex(k)
+			return ierr
+		})
+		if err != nil {
+			if cerr := reader.Close(); cerr != nil {
+				log.Errorf("failed to close mount reader: %s", cerr)
+			}
+			return nil, nil, fmt.Errorf("failed to recover index for shard %s: %w", k, err)
 		}
+		return reader, idx, nil
+	})
+	if err != nil {
+		inst.recordFailure(ctx, "acquire")
 
 		// release the shard to decrement the refcount that's incremented before `acquireAsync` is called.
 		_ = d.queueTask(&task{op: OpShardRelease, shard: s}, d.completionCh)
 
 		// fail the shard
-		_ = d.queueTask(&task{op: OpShardFail, shard: s, err: fmt.Errorf("failed to recover index for shard %s: %w", k, err)}, d.completionCh)
+		_ = d.queueTask(&task{op: OpShardFail, shard: s, err: err}, d.completionCh)
 
 		// send the shard error to the caller.
 		d.sendResult(&ShardResult{Key: k, Error: err}, w)
 		return
 	}
 
-	sa, err := NewShardAccessor(reader, idx, s)
+	wrapped := &releasingReader{Reader: reader, release: release}
+	sa, err := NewShardAccessor(wrapped, idx, s)
+
+	if err == nil {
+		if berr := d.registerShardBitswap(k, idx, wrapped); berr != nil {
+			log.Errorf("failed to register shard %s with bitswap: %s", k, berr)
+		}
+	}
 
 	// send the shard accessor to the caller.
 	d.sendResult(&ShardResult{Key: k, Accessor: sa, Error: err}, w)
@@ -56,32 +105,102 @@ func (d *DAGStore) acquireAsync(ctx context.Context, w *waiter, s *Shard, mnt mo
 // initializeAsync initializes a shard asynchronously by fetching its data and
 // performing indexing.
 func (d *DAGStore) initializeAsync(ctx context.Context, s *Shard, mnt mount.Mount) {
-	reader, err := mnt.Fetch(ctx)
+	ctx, span := startShardSpan(ctx, "DAGStore.initializeAsync", s.key, mnt)
+	inst := d.instrumentationOrDefault()
+	var spanErr error
+	defer func() { endShardSpan(span, spanErr) }()
+
+	unlock := d.shardLocks.lock(s.key)
+	defer unlock()
+
+	mountKind := mnt.Info().Kind.String()
+
+	var reader mount.Reader
+	fetchStart := time.Now()
+	err := withSubSpan(ctx, "mnt.Fetch", func(ctx context.Context) error {
+		return d.jobScheduler().Schedule(ctx, JobClassFetch, PriorityBackground, mountKind, func() error {
+			var ferr error
+			reader, ferr = mnt.Fetch(ctx)
+			return ferr
+		})
+	})
+	inst.recordFetch(ctx, time.Since(fetchStart), mountKind, err)
 	if err != nil {
-		_ = d.failShard(s, fmt.Errorf("failed to acquire reader of mount: %w", err), d.completionCh)
+		spanErr = err
+		inst.recordFailure(ctx, "fetch")
+		_ = d.failShard(ctx, s, fmt.Errorf("failed to acquire reader of mount: %w", err), d.completionCh)
 		return
 	}
 	defer reader.Close()
 
-	// works for both CARv1 and CARv2.
-	// TODO avoid using this API since it's too opaque; if an inline index
-	//  exists, this API returns quickly, if not, an index will be generated
-	//  which is a costly operation in terms of IO and wall clock time. The DAG
-	//  store will need to have control over scheduling of index generation.
-	//  https://github.com/filecoin-project/dagstore/issues/50
-	idx, err := car.ReadOrGenerateIndex(reader)
+	// Probing for an inline index is cheap (it only inspects the CARv2
+	// header), so it's never throttled; it just decides which pool below
+	// gets to run the rest of the work, addressing
+	// https://github.com/filecoin-project/dagstore/issues/50 by giving the
+	// scheduler control over the expensive "generate" path instead of
+	// calling car.ReadOrGenerateIndex opaquely.
+	hasInline, probeErr := hasInlineIndex(reader)
+	jobClass := JobClassIndexGenerate
+	if probeErr == nil && hasInline {
+		jobClass = JobClassIndexLoad
+	}
+
+	var idx index.Index
+	indexStart := time.Now()
+	err = withSubSpan(ctx, "car.ReadOrGenerateIndex", func(ctx context.Context) error {
+		return d.jobScheduler().Schedule(ctx, jobClass, PriorityBackground, mountKind, func() error {
+			var gerr error
+			if hasInline {
+				// fast path: an inline index is already present, so reading
+				// it is cheap and doesn't need streaming/checkpointing.
+				idx, gerr = car.ReadOrGenerateIndex(reader)
+				return gerr
+			}
+			// slow path: no inline index, so stream the CAR and checkpoint
+			// progress instead of calling car.ReadOrGenerateIndex blindly,
+			// so a restart resumes rather than rescanning from byte zero.
+			idx, gerr = d.streamingIndexer().Generate(ctx, s.key, reader, s.totalBytes)
+			return gerr
+		})
+	})
+	inst.recordIndexGen(ctx, time.Since(indexStart), mountKind, err)
 	if err != nil {
-		_ = d.failShard(s, fmt.Errorf("failed to read/generate CAR Index: %w", err), d.completionCh)
+		spanErr = err
+		inst.recordFailure(ctx, "index-generate")
+		_ = d.failShard(ctx, s, fmt.Errorf("failed to read/generate CAR Index: %w", err), d.completionCh)
 		return
 	}
-	if err := d.indices.AddFullIndex(s.key, idx); err != nil {
-		_ = d.failShard(s, fmt.Errorf("failed to add index for shard: %w", err), d.completionCh)
+	err = withSubSpan(ctx, "indices.AddFullIndex", func(ctx context.Context) error {
+		return d.indices.AddFullIndex(s.key, idx)
+	})
+	if err != nil {
+		spanErr = err
+		inst.recordFailure(ctx, "index-add")
+		_ = d.failShard(ctx, s, fmt.Errorf("failed to add index for shard: %w", err), d.completionCh)
 		return
 	}
 
 	_ = d.queueTask(&task{op: OpShardMakeAvailable, shard: s}, d.completionCh)
 }
 
-func (d *DAGStore) failShard(s *Shard, err error, ch chan *task) error {
-	return d.queueTask(&task{op: OpShardFail, shard: s, err: err}, ch)
+// failShard queues an OpShardFail task for s, wrapped in its own span so
+// that a failure originating deep in acquireAsync/initializeAsync (where
+// the parent span may already have ended) is still visible as its own
+// traced event rather than silently attributed to nothing.
+func (d *DAGStore) failShard(ctx context.Context, s *Shard, err error, ch chan *task) error {
+	_, span := startShardSpan(ctx, "DAGStore.failShard", s.key, nil)
+	qerr := d.queueTask(&task{op: OpShardFail, shard: s, err: err}, ch)
+	endShardSpan(span, err)
+	return qerr
+}
+
+// hasInlineIndex reports whether reader already carries a CARv2 index, by
+// inspecting the CARv2 header only; it never scans the payload, so it's
+// safe to call outside of the index-generate pool.
+func hasInlineIndex(reader mount.Reader) (bool, error) {
+	v2r, err := car.NewReader(reader)
+	if err != nil {
+		return false, err
+	}
+	return v2r.Header.HasIndex(), nil
 }
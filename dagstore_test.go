@@ -0,0 +1,82 @@
+package dagstore
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/filecoin-project/dagstore/mount"
+	"github.com/filecoin-project/dagstore/shard"
+	"github.com/ipld/go-car/v2/index"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeIndexRepo is a minimal IndexRepo for tests that only need a value to
+// satisfy NewDAGStore, not real persistence.
+type fakeIndexRepo struct{}
+
+func (fakeIndexRepo) GetFullIndex(shard.Key) (index.Index, error) { return nil, nil }
+func (fakeIndexRepo) AddFullIndex(shard.Key, index.Index) error   { return nil }
+
+func TestNewDAGStoreDefaults(t *testing.T) {
+	d := NewDAGStore(fakeIndexRepo{})
+
+	require.NotNil(t, d.accessorCache)
+	require.NotNil(t, d.indexingProgress)
+	require.Nil(t, d.scheduler, "scheduler should stay nil (and fall back to noopScheduler) unless WithSchedulerConfig is supplied")
+	require.Nil(t, d.instrumentation, "instrumentation should stay nil (and fall back lazily) unless WithMeterProvider is supplied")
+}
+
+func TestDAGStoreOptionsWireFields(t *testing.T) {
+	registrar := newFakeBitswapRegistrar()
+	checkpoints := newMemCheckpointStore()
+	bp := DefaultBlockstoreProvider
+
+	d := NewDAGStore(fakeIndexRepo{},
+		WithSchedulerConfig(SchedulerConfig{MaxConcurrentFetches: 2}),
+		WithIndexCheckpointStore(checkpoints),
+		WithBitswapSessionRegistrar(registrar),
+		WithBlockstoreProvider(bp),
+	)
+
+	require.NotNil(t, d.scheduler)
+	require.Same(t, checkpoints, d.indexCheckpoints)
+	require.Same(t, registrar, d.bitswapRegistrar)
+	require.NotNil(t, d.blockstoreProvider)
+}
+
+// TestDAGStoreDispatchesDestroyAndRelease is the regression test for the
+// event loop actually existing: OpShardDestroy/OpShardRelease tasks queued
+// onto completionCh must reach handleShardDestroy/handleShardRelease, not
+// sit as dead code with nothing ever invoking them.
+func TestDAGStoreDispatchesDestroyAndRelease(t *testing.T) {
+	registrar := newFakeBitswapRegistrar()
+	d := NewDAGStore(fakeIndexRepo{}, WithBitswapSessionRegistrar(registrar))
+	k := shard.KeyFromString("s1")
+
+	// Seed the accessor cache with an entry for k so OpShardDestroy's
+	// Invalidate has something observable to act on.
+	_, _, release, err := d.accessorCache.acquire(context.Background(), k, func(ctx context.Context) (mount.Reader, index.Index, error) {
+		return fakeMountReader{}, nil, nil
+	})
+	require.NoError(t, err)
+	defer release()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	require.NoError(t, d.Start(ctx))
+
+	s := &Shard{key: k}
+	require.NoError(t, d.queueTask(&task{op: OpShardDestroy, shard: s}, d.completionCh))
+	require.NoError(t, d.queueTask(&task{op: OpShardRelease, shard: s}, d.completionCh))
+
+	require.Eventually(t, func() bool {
+		registrar.mu.Lock()
+		defer registrar.mu.Unlock()
+		return len(registrar.unregistered) == 1
+	}, time.Second, time.Millisecond, "OpShardRelease task should have reached handleShardRelease")
+
+	require.Eventually(t, func() bool {
+		return d.accessorCache.Stats().Size == 0
+	}, time.Second, time.Millisecond, "OpShardDestroy task should have reached handleShardDestroy and invalidated the cache entry")
+}
@@ -0,0 +1,24 @@
+package dagstore
+
+// handleShardDestroy performs the cross-cutting teardown a destroyed
+// shard needs: dropping it from the accessor cache so its reader isn't
+// kept warm for a shard that no longer exists, and clearing any
+// in-progress indexing bookkeeping. It's invoked by the OpShardDestroy
+// case in the DAGStore's event loop.
+func (d *DAGStore) handleShardDestroy(s *Shard) {
+	d.accessorCache.Invalidate(s.key)
+	if d.indexingProgress != nil {
+		d.indexingProgress.clear(s.key)
+	}
+}
+
+// handleShardRelease unplugs a released shard from bitswap, so it stops
+// serving blocks to remote peers. It's invoked by the OpShardRelease case
+// in the DAGStore's event loop, the counterpart to registerShardBitswap in
+// acquireAsync, and unregisters on every release without checking whether
+// other acquirers of the shard are still holding it.
+func (d *DAGStore) handleShardRelease(s *Shard) {
+	if err := d.unregisterShardBitswap(s.key); err != nil {
+		log.Errorf("failed to unregister shard %s from bitswap: %s", s.key, err)
+	}
+}
@@ -0,0 +1,362 @@
+package dagstore
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// JobClass identifies the category of work being scheduled. Jobs in
+// different classes are throttled independently so that, for example, a
+// burst of index generation does not starve mount fetches.
+type JobClass int
+
+const (
+	// JobClassFetch covers calls to mount.Reader acquisition (mnt.Fetch).
+	JobClassFetch JobClass = iota
+	// JobClassIndexGenerate covers full CAR scans that generate an index
+	// from scratch (the expensive path of car.ReadOrGenerateIndex).
+	JobClassIndexGenerate
+	// JobClassIndexLoad covers the cheap path of reading an inline index
+	// that's already present in the CARv2 file.
+	JobClassIndexLoad
+)
+
+func (c JobClass) String() string {
+	switch c {
+	case JobClassFetch:
+		return "fetch"
+	case JobClassIndexGenerate:
+		return "index-generate"
+	case JobClassIndexLoad:
+		return "index-load"
+	default:
+		return "unknown"
+	}
+}
+
+// JobPriority distinguishes work requested synchronously by a caller
+// blocked on AcquireShard from work the DAGStore performs in the
+// background, such as indexing on RegisterShard. When a slot frees up,
+// waiters queued at PriorityInteractive are woken before any queued at
+// PriorityBackground, regardless of arrival order.
+type JobPriority int
+
+const (
+	// PriorityBackground is used for registration and other work that has
+	// no caller waiting on it.
+	PriorityBackground JobPriority = iota
+	// PriorityInteractive is used for work blocking an AcquireShard caller.
+	PriorityInteractive
+)
+
+// SchedulerStats is a point-in-time snapshot of scheduler occupancy for a
+// single job class, suitable for exposing via metrics.
+type SchedulerStats struct {
+	Queued      int
+	Running     int
+	AvgWaitTime time.Duration
+}
+
+// IndexJobScheduler gates index generation and mount fetches performed by
+// acquireAsync and initializeAsync behind bounded worker pools, so that a
+// DAGStore with many shards registering or being acquired concurrently
+// doesn't turn every one of them loose on the disk/network at once.
+//
+// Implementations are expected to be safe for concurrent use.
+type IndexJobScheduler interface {
+	// Schedule blocks until a slot is available for the given job class,
+	// priority and mount type, then runs fn. The slot is released when fn
+	// returns. If ctx is cancelled before a slot becomes available,
+	// Schedule returns ctx.Err() without running fn.
+	Schedule(ctx context.Context, class JobClass, priority JobPriority, mountType string, fn func() error) error
+
+	// Stats returns current occupancy for the given job class.
+	Stats(class JobClass) SchedulerStats
+
+	// Close releases any resources held by the scheduler. Outstanding
+	// calls to Schedule are not interrupted.
+	Close() error
+}
+
+// SchedulerConfig bounds the worker pools a boundedScheduler hands out.
+type SchedulerConfig struct {
+	// MaxConcurrentFetches caps the number of mount.Fetch calls in flight
+	// across all mount types. Zero means unbounded.
+	MaxConcurrentFetches int
+	// MaxConcurrentIndexGenerate caps the number of full CAR index
+	// generations in flight. Zero means unbounded.
+	MaxConcurrentIndexGenerate int
+	// MaxConcurrentIndexLoad caps the number of inline-index reads in
+	// flight. Zero means unbounded.
+	MaxConcurrentIndexLoad int
+	// MaxConcurrentPerMountType further caps concurrency per mount type
+	// (e.g. "lotus", "http", "fs"), on top of the per-class cap above.
+	// A mount type absent from this map is subject only to the per-class
+	// cap.
+	MaxConcurrentPerMountType map[string]int
+}
+
+// boundedScheduler is the default IndexJobScheduler. It hands out slots
+// from a priorityGate per job class, plus an optional secondary
+// priorityGate per mount type, and tracks queued/running/wait-time stats.
+type boundedScheduler struct {
+	classGates map[JobClass]*priorityGate
+
+	mu        sync.Mutex
+	mountGates map[string]*priorityGate
+	mountCaps  map[string]int
+
+	statsMu   sync.Mutex
+	queued    map[JobClass]int
+	running   map[JobClass]int
+	waitNanos map[JobClass]int64
+	waitCount map[JobClass]int64
+}
+
+var _ IndexJobScheduler = (*boundedScheduler)(nil)
+
+// NewBoundedScheduler constructs an IndexJobScheduler backed by bounded
+// worker pools, one per JobClass, with an optional secondary cap per mount
+// type. PriorityInteractive jobs jump the per-class queue ahead of
+// PriorityBackground jobs; within a priority tier, jobs are served FIFO.
+func NewBoundedScheduler(cfg SchedulerConfig) IndexJobScheduler {
+	s := &boundedScheduler{
+		classGates: make(map[JobClass]*priorityGate),
+		mountGates: make(map[string]*priorityGate),
+		mountCaps:  cfg.MaxConcurrentPerMountType,
+		queued:     make(map[JobClass]int),
+		running:    make(map[JobClass]int),
+		waitNanos:  make(map[JobClass]int64),
+		waitCount:  make(map[JobClass]int64),
+	}
+	s.classGates[JobClassFetch] = newPriorityGate(cfg.MaxConcurrentFetches)
+	s.classGates[JobClassIndexGenerate] = newPriorityGate(cfg.MaxConcurrentIndexGenerate)
+	s.classGates[JobClassIndexLoad] = newPriorityGate(cfg.MaxConcurrentIndexLoad)
+	return s
+}
+
+func (s *boundedScheduler) Schedule(ctx context.Context, class JobClass, priority JobPriority, mountType string, fn func() error) error {
+	classGate := s.classGates[class]
+	mountGate := s.mountGateFor(mountType)
+
+	s.trackQueued(class, 1)
+	queuedAt := time.Now()
+
+	if err := classGate.acquire(ctx, priority); err != nil {
+		s.trackQueued(class, -1)
+		return err
+	}
+	defer classGate.release()
+
+	if err := mountGate.acquire(ctx, priority); err != nil {
+		s.trackQueued(class, -1)
+		return err
+	}
+	defer mountGate.release()
+
+	s.trackQueued(class, -1)
+	s.trackWait(class, schedulerWaitTime(queuedAt))
+
+	s.trackRunning(class, 1)
+	defer s.trackRunning(class, -1)
+
+	return fn()
+}
+
+// mountGateFor lazily creates the per-mount-type gate on first use, since
+// the set of mount types isn't known up front. It returns a fresh
+// capacity-0 (unbounded) gate for mount types with no configured cap.
+func (s *boundedScheduler) mountGateFor(mountType string) *priorityGate {
+	n, ok := s.mountCaps[mountType]
+	if !ok || n <= 0 {
+		return newPriorityGate(0)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if g, ok := s.mountGates[mountType]; ok {
+		return g
+	}
+	g := newPriorityGate(n)
+	s.mountGates[mountType] = g
+	return g
+}
+
+func (s *boundedScheduler) trackQueued(class JobClass, delta int) {
+	s.statsMu.Lock()
+	s.queued[class] += delta
+	s.statsMu.Unlock()
+}
+
+func (s *boundedScheduler) trackRunning(class JobClass, delta int) {
+	s.statsMu.Lock()
+	s.running[class] += delta
+	s.statsMu.Unlock()
+}
+
+func (s *boundedScheduler) trackWait(class JobClass, d time.Duration) {
+	s.statsMu.Lock()
+	s.waitNanos[class] += d.Nanoseconds()
+	s.waitCount[class]++
+	s.statsMu.Unlock()
+}
+
+func (s *boundedScheduler) Stats(class JobClass) SchedulerStats {
+	s.statsMu.Lock()
+	defer s.statsMu.Unlock()
+	var avgWait time.Duration
+	if n := s.waitCount[class]; n > 0 {
+		avgWait = time.Duration(s.waitNanos[class] / n)
+	}
+	return SchedulerStats{Queued: s.queued[class], Running: s.running[class], AvgWaitTime: avgWait}
+}
+
+func (s *boundedScheduler) Close() error {
+	return nil
+}
+
+// noopScheduler runs every job inline with no throttling. It's the
+// scheduler a DAGStore uses when none is supplied via options, preserving
+// today's behaviour for callers that don't opt in.
+type noopScheduler struct{}
+
+var _ IndexJobScheduler = noopScheduler{}
+
+func (noopScheduler) Schedule(_ context.Context, _ JobClass, _ JobPriority, _ string, fn func() error) error {
+	return fn()
+}
+
+func (noopScheduler) Stats(JobClass) SchedulerStats { return SchedulerStats{} }
+
+func (noopScheduler) Close() error { return nil }
+
+// schedulerWaitTime computes how long a job sat queued before Schedule ran
+// it, for recording in SchedulerStats.AvgWaitTime.
+func schedulerWaitTime(start time.Time) time.Duration {
+	return time.Since(start)
+}
+
+// jobScheduler returns d.scheduler, falling back to a noopScheduler so that
+// a DAGStore constructed without an IndexJobScheduler keeps today's
+// unthrottled behaviour.
+func (d *DAGStore) jobScheduler() IndexJobScheduler {
+	if d.scheduler != nil {
+		return d.scheduler
+	}
+	return noopScheduler{}
+}
+
+// gateTicket is a single waiter's place in a priorityGate's queue. woken is
+// guarded by the owning priorityGate's mutex: it's set to true exactly when
+// the gate hands the ticket's holder a slot (by closing ready), so a waiter
+// racing against ctx cancellation can tell, under the same lock, whether it
+// already owns a slot it must release, or whether it's still queued and can
+// simply drop out.
+type gateTicket struct {
+	ready chan struct{}
+	woken bool
+}
+
+// priorityGate is a counting semaphore with two priority tiers:
+// PriorityInteractive waiters are served before any queued PriorityBackground
+// waiter, regardless of arrival order; within a tier, service is FIFO. A
+// priorityGate with capacity <= 0 is unbounded and never blocks.
+type priorityGate struct {
+	capacity int
+
+	mu          sync.Mutex
+	inUse       int
+	interactive []*gateTicket
+	background  []*gateTicket
+}
+
+func newPriorityGate(capacity int) *priorityGate {
+	return &priorityGate{capacity: capacity}
+}
+
+// acquire blocks until a slot is available at the given priority, or ctx is
+// done. A priorityGate with capacity <= 0 always succeeds immediately.
+func (g *priorityGate) acquire(ctx context.Context, priority JobPriority) error {
+	if g == nil || g.capacity <= 0 {
+		return nil
+	}
+
+	g.mu.Lock()
+	if g.inUse < g.capacity {
+		g.inUse++
+		g.mu.Unlock()
+		return nil
+	}
+	t := &gateTicket{ready: make(chan struct{})}
+	if priority == PriorityInteractive {
+		g.interactive = append(g.interactive, t)
+	} else {
+		g.background = append(g.background, t)
+	}
+	g.mu.Unlock()
+
+	select {
+	case <-t.ready:
+		return nil
+	case <-ctx.Done():
+		g.mu.Lock()
+		if t.woken {
+			// We were already handed a slot concurrently with ctx being
+			// cancelled; give it back rather than leaking it.
+			g.mu.Unlock()
+			g.release()
+			return ctx.Err()
+		}
+		g.removeTicketLocked(priority, t)
+		g.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// release hands the freed slot to the next queued waiter (interactive
+// tier first), or returns it to the pool if nobody's waiting.
+func (g *priorityGate) release() {
+	if g == nil || g.capacity <= 0 {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	if t := popTicket(&g.interactive); t != nil {
+		t.woken = true
+		close(t.ready)
+		return
+	}
+	if t := popTicket(&g.background); t != nil {
+		t.woken = true
+		close(t.ready)
+		return
+	}
+	g.inUse--
+}
+
+// removeTicketLocked drops t from the given priority's wait queue. Must be
+// called with g.mu held.
+func (g *priorityGate) removeTicketLocked(priority JobPriority, t *gateTicket) {
+	q := &g.background
+	if priority == PriorityInteractive {
+		q = &g.interactive
+	}
+	for i, qt := range *q {
+		if qt == t {
+			*q = append((*q)[:i], (*q)[i+1:]...)
+			return
+		}
+	}
+}
+
+// popTicket removes and returns the first ticket in *q, or nil if empty.
+func popTicket(q *[]*gateTicket) *gateTicket {
+	if len(*q) == 0 {
+		return nil
+	}
+	t := (*q)[0]
+	*q = (*q)[1:]
+	return t
+}
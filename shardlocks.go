@@ -0,0 +1,43 @@
+package dagstore
+
+import (
+	"hash/fnv"
+	"sync"
+
+	"github.com/filecoin-project/dagstore/shard"
+)
+
+// shardLockStripes is the width of the striped lock table. 256 stripes
+// keeps contention low for realistic shard counts while bounding memory to
+// a fixed, small footprint regardless of how many shards are registered.
+const shardLockStripes = 256
+
+// shardLocks is a fixed-size table of mutexes keyed by a hash of
+// shard.Key. It replaces serializing all acquire/release state
+// transitions through the single DAGStore event loop: initializeAsync
+// takes the stripe for its shard's key before touching mount readers or
+// the index store, so initializations of distinct shards run in parallel,
+// while repeated initializations of the same shard are serialized through
+// the same stripe. acquireAsync does not take it; concurrent acquires of
+// the same shard are deduplicated by the accessorCache instead.
+//
+// A shardLocks is safe for concurrent use and requires no initialization
+// beyond its zero value.
+type shardLocks struct {
+	stripes [shardLockStripes]sync.Mutex
+}
+
+// lock acquires the stripe for k and returns a function that releases it.
+// Callers are expected to defer the returned function.
+func (l *shardLocks) lock(k shard.Key) func() {
+	m := &l.stripes[shardStripe(k)]
+	m.Lock()
+	return m.Unlock
+}
+
+// shardStripe hashes k.String() to a stripe index in [0, shardLockStripes).
+func shardStripe(k shard.Key) uint32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(k.String()))
+	return h.Sum32() % shardLockStripes
+}
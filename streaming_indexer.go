@@ -0,0 +1,209 @@
+package dagstore
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+
+	"github.com/filecoin-project/dagstore/mount"
+	"github.com/filecoin-project/dagstore/shard"
+	carv2 "github.com/ipld/go-car/v2"
+	"github.com/ipld/go-car/v2/index"
+)
+
+// IndexCheckpoint captures enough state to resume a streaming index build
+// without rescanning everything already read: the byte offset reached in
+// the CARv1 payload, and the index records accumulated up to that offset.
+type IndexCheckpoint struct {
+	BytesScanned int64
+	Records      []index.Record
+}
+
+// IndexCheckpointStore persists IndexCheckpoints for in-progress index
+// builds, keyed by shard. It's deliberately minimal (load/save/delete) so
+// it can be backed by anything from a bbolt bucket to a plain directory of
+// files, matching how the rest of dagstore treats its pluggable stores
+// (c.f. index.FullIndexRepo).
+type IndexCheckpointStore interface {
+	Load(key shard.Key) (IndexCheckpoint, bool, error)
+	Save(key shard.Key, cp IndexCheckpoint) error
+	Delete(key shard.Key) error
+}
+
+// IndexingProgress reports how far a streaming index build has gotten, for
+// a shard currently in initializeAsync. It's recorded per shard by
+// indexingProgressTracker and read back through its Progress method; it
+// isn't attached to any other per-shard state.
+type IndexingProgress struct {
+	BytesScanned int64
+	TotalBytes   int64
+}
+
+// Percent returns the completion percentage in [0, 100], or 0 if
+// TotalBytes isn't known yet.
+func (p IndexingProgress) Percent() float64 {
+	if p.TotalBytes <= 0 {
+		return 0
+	}
+	return 100 * float64(p.BytesScanned) / float64(p.TotalBytes)
+}
+
+// indexingProgressTracker records IndexingProgress per shard so it can be
+// looked up by key while initializeAsync is still running; entries are
+// removed once indexing finishes, fails, or the shard is destroyed.
+type indexingProgressTracker struct {
+	mu    sync.Mutex
+	byKey map[shard.Key]IndexingProgress
+}
+
+func newIndexingProgressTracker() *indexingProgressTracker {
+	return &indexingProgressTracker{byKey: make(map[shard.Key]IndexingProgress)}
+}
+
+func (t *indexingProgressTracker) set(k shard.Key, p IndexingProgress) {
+	t.mu.Lock()
+	t.byKey[k] = p
+	t.mu.Unlock()
+}
+
+func (t *indexingProgressTracker) clear(k shard.Key) {
+	t.mu.Lock()
+	delete(t.byKey, k)
+	t.mu.Unlock()
+}
+
+// Progress returns the current IndexingProgress for key, if it's being
+// indexed right now.
+func (t *indexingProgressTracker) Progress(k shard.Key) (IndexingProgress, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p, ok := t.byKey[k]
+	return p, ok
+}
+
+// streamingIndexer builds a CARv2 index incrementally, checkpointing
+// progress so that a restart resumes from the last checkpoint instead of
+// rescanning the full file. It supersedes the direct call to
+// car.ReadOrGenerateIndex for the "no inline index present" case, which is
+// the expensive path flagged by
+// https://github.com/filecoin-project/dagstore/issues/50.
+type streamingIndexer struct {
+	checkpoints     IndexCheckpointStore
+	progress        *indexingProgressTracker
+	checkpointEvery int64 // checkpoint after at least this many bytes scanned since the last one
+}
+
+// newStreamingIndexer constructs a streamingIndexer. A nil checkpoints
+// store disables checkpointing (every call starts from scratch, same as
+// the non-resumable behaviour this replaces); checkpointEvery <= 0 uses a
+// 64MiB default.
+func newStreamingIndexer(checkpoints IndexCheckpointStore, progress *indexingProgressTracker, checkpointEvery int64) *streamingIndexer {
+	if checkpointEvery <= 0 {
+		checkpointEvery = 64 << 20
+	}
+	return &streamingIndexer{checkpoints: checkpoints, progress: progress, checkpointEvery: checkpointEvery}
+}
+
+// streamingIndexer lazily builds a streamingIndexer from d's configured
+// IndexCheckpointStore and progress tracker, so initializeAsync doesn't
+// need to nil-check either of them.
+func (d *DAGStore) streamingIndexer() *streamingIndexer {
+	return newStreamingIndexer(d.indexCheckpoints, d.indexingProgress, 0)
+}
+
+// Generate streams reader as a CARv1 payload, building an index.Index one
+// block at a time. If a checkpoint is found for key, it resumes from
+// there instead of starting at offset 0. Progress is reported through the
+// streamingIndexer's tracker as scanning proceeds, and cleared when
+// Generate returns. ctx is checked between blocks, so cancelling it stops
+// the scan promptly; destroying the shard does not by itself cancel ctx.
+func (si *streamingIndexer) Generate(ctx context.Context, key shard.Key, reader mount.Reader, totalBytes int64) (index.Index, error) {
+	var records []index.Record
+	var resumeOffset int64
+
+	if si.progress != nil {
+		defer si.progress.clear(key)
+	}
+
+	if si.checkpoints != nil {
+		if cp, ok, err := si.checkpoints.Load(key); err == nil && ok {
+			records = cp.Records
+			resumeOffset = cp.BytesScanned
+		}
+	}
+
+	// carv2.NewBlockReader expects to read the CARv1 header (roots +
+	// version) from the reader's current position, so it must always be
+	// opened at offset 0 - seeking straight to resumeOffset first would
+	// have it try to decode the middle of a block as a header. Once the
+	// header's been parsed, it's safe to seek past the blocks a previous
+	// run already scanned, since carv2.BlockReader has no internal state
+	// beyond "keep reading whatever's next on the wrapped reader".
+	if _, err := reader.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to seek to CAR header: %w", err)
+	}
+
+	br, err := carv2.NewBlockReader(reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CAR block reader: %w", err)
+	}
+
+	if resumeOffset > 0 {
+		if _, err := reader.Seek(resumeOffset, io.SeekStart); err != nil {
+			return nil, fmt.Errorf("failed to seek to checkpointed offset %d: %w", resumeOffset, err)
+		}
+	}
+
+	lastCheckpoint := resumeOffset
+	for {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+
+		offset, err := reader.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read current offset: %w", err)
+		}
+
+		// Checkpoint before reading the block at offset, so the saved
+		// records are exactly those of the blocks before offset and a
+		// resume that seeks to offset re-reads nothing already recorded.
+		if si.checkpoints != nil && offset-lastCheckpoint >= si.checkpointEvery {
+			if err := si.checkpoints.Save(key, IndexCheckpoint{BytesScanned: offset, Records: records}); err != nil {
+				return nil, fmt.Errorf("failed to save index checkpoint: %w", err)
+			}
+			lastCheckpoint = offset
+		}
+
+		blk, err := br.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read next CAR block: %w", err)
+		}
+
+		records = append(records, index.Record{Cid: blk.Cid(), IndexEntry: index.IndexEntry{Offset: uint64(offset)}})
+
+		if si.progress != nil {
+			si.progress.set(key, IndexingProgress{BytesScanned: offset, TotalBytes: totalBytes})
+		}
+	}
+
+	idx, err := index.New(carv2.IndexSorted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to construct index: %w", err)
+	}
+	if err := idx.Load(records); err != nil {
+		return nil, fmt.Errorf("failed to load records into index: %w", err)
+	}
+
+	if si.checkpoints != nil {
+		if err := si.checkpoints.Delete(key); err != nil {
+			log.Errorf("failed to delete completed index checkpoint for shard %s: %s", key, err)
+		}
+	}
+
+	return idx, nil
+}
@@ -0,0 +1,166 @@
+package dagstore
+
+import (
+	"context"
+	"time"
+
+	"github.com/filecoin-project/dagstore/mount"
+	"github.com/filecoin-project/dagstore/shard"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName is used as both the otel tracer name and the metric
+// meter name, so that every span and instrument this package emits is
+// attributable to dagstore in a multi-library trace/metrics backend.
+const instrumentationName = "github.com/filecoin-project/dagstore"
+
+var tracer = otel.Tracer(instrumentationName)
+
+// instrumentation bundles the counters and histograms emitted around the
+// async shard pipeline. A DAGStore with no MeterProvider configured uses a
+// noop MeterProvider (the otel default), so these calls are always safe
+// even when nobody is collecting metrics.
+type instrumentation struct {
+	fetchDuration     metric.Float64Histogram
+	indexGenDuration  metric.Float64Histogram
+	acquireQueueDepth metric.Int64UpDownCounter
+	failures          metric.Int64Counter
+}
+
+// newInstrumentation creates the instruments dagstore emits, using mp as
+// the MeterProvider. Passing nil falls back to the global MeterProvider
+// (otel.GetMeterProvider()), matching how other otel-instrumented
+// libraries in the ecosystem default when a caller doesn't opt in.
+func newInstrumentation(mp metric.MeterProvider) (*instrumentation, error) {
+	if mp == nil {
+		mp = otel.GetMeterProvider()
+	}
+	meter := mp.Meter(instrumentationName)
+
+	fetchDuration, err := meter.Float64Histogram(
+		"dagstore.fetch.duration",
+		metric.WithDescription("duration of mount.Fetch calls"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	indexGenDuration, err := meter.Float64Histogram(
+		"dagstore.index.generate.duration",
+		metric.WithDescription("duration of CAR index read/generate calls"),
+		metric.WithUnit("s"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	acquireQueueDepth, err := meter.Int64UpDownCounter(
+		"dagstore.acquire.queue_depth",
+		metric.WithDescription("number of acquireAsync calls currently in flight"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	failures, err := meter.Int64Counter(
+		"dagstore.shard.failures",
+		metric.WithDescription("shard failures, labelled by reason"),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return &instrumentation{
+		fetchDuration:     fetchDuration,
+		indexGenDuration:  indexGenDuration,
+		acquireQueueDepth: acquireQueueDepth,
+		failures:          failures,
+	}, nil
+}
+
+// instrumentationOrDefault returns d.instrumentation, lazily falling back
+// to an instrumentation built from the global MeterProvider so callers
+// never need to nil-check.
+func (d *DAGStore) instrumentationOrDefault() *instrumentation {
+	if d.instrumentation != nil {
+		return d.instrumentation
+	}
+	inst, err := newInstrumentation(nil)
+	if err != nil {
+		// The global noop MeterProvider cannot fail to produce
+		// instruments; a non-nil err here means a caller registered a
+		// broken MeterProvider, in which case we fall back to recording
+		// nothing rather than panicking on every acquire.
+		return &instrumentation{}
+	}
+	return inst
+}
+
+func (i *instrumentation) recordFetch(ctx context.Context, d time.Duration, mountKind string, err error) {
+	if i == nil || i.fetchDuration == nil {
+		return
+	}
+	i.fetchDuration.Record(ctx, d.Seconds(), metric.WithAttributes(
+		attribute.String("mount.kind", mountKind),
+		attribute.Bool("error", err != nil),
+	))
+}
+
+func (i *instrumentation) recordIndexGen(ctx context.Context, d time.Duration, mountKind string, err error) {
+	if i == nil || i.indexGenDuration == nil {
+		return
+	}
+	i.indexGenDuration.Record(ctx, d.Seconds(), metric.WithAttributes(
+		attribute.String("mount.kind", mountKind),
+		attribute.Bool("error", err != nil),
+	))
+}
+
+func (i *instrumentation) recordFailure(ctx context.Context, reason string) {
+	if i == nil || i.failures == nil {
+		return
+	}
+	i.failures.Add(ctx, 1, metric.WithAttributes(attribute.String("reason", reason)))
+}
+
+// startShardSpan starts a span for one of the async pipeline's entry
+// points (acquireAsync, initializeAsync, failShard), tagged with the
+// shard key and mount attributes that operators need to correlate a slow
+// span with the shard and backend involved.
+func startShardSpan(ctx context.Context, name string, k shard.Key, mnt mount.Mount) (context.Context, trace.Span) {
+	attrs := []attribute.KeyValue{attribute.String("shard.key", k.String())}
+	if mnt != nil {
+		info := mnt.Info()
+		attrs = append(attrs,
+			attribute.String("mount.kind", info.Kind.String()),
+			attribute.Bool("mount.local", info.Local),
+		)
+	}
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// endShardSpan records err on span (if any) and ends it, following the
+// otel convention of setting a non-OK status only on genuine failures.
+func endShardSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// withSubSpan wraps fn in a child span named name, so that operators can
+// see where time goes within acquireAsync/initializeAsync - e.g.
+// mnt.Fetch vs indices.GetFullIndex vs car.ReadOrGenerateIndex - instead
+// of only seeing the two top-level spans' total duration.
+func withSubSpan(ctx context.Context, name string, fn func(ctx context.Context) error) error {
+	ctx, span := tracer.Start(ctx, name)
+	err := fn(ctx)
+	endShardSpan(span, err)
+	return err
+}